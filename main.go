@@ -1,91 +1,13 @@
 package main
 
 import (
-	"encoding/json"
 	"fmt"
 	"os"
-	"os/exec"
-	"sort"
 	"strings"
 
-	"github.com/alessio/shellescape"
-	"github.com/tidwall/jsonc"
+	"github.com/MawKKe/vscode-cmake-configure/pkg/cmakecfg"
 )
 
-// VSCodeSettings is a struct representing VCode settings.json relating to CMake options
-type VSCodeSettings struct {
-	CMakeConfigureSettings  map[string]string `json:"cmake.configureSettings"`
-	CMakeConfigureArguments []string          `json:"cmake.configureArgs"`
-}
-
-// ReadVSCodeSettings extracts CMake -DKEY=VALUE parameters from given input file
-func ReadVSCodeSettings(inputFile string) (VSCodeSettings, error) {
-	contents, err := os.ReadFile(inputFile)
-	if err != nil {
-		return VSCodeSettings{}, err
-	}
-	return ParseVSCodeSettings(contents)
-}
-
-// ParseVSCodeSettings extracts CMake -DKEY=VALUE parameters from given input byte slice
-func ParseVSCodeSettings(inputString []byte) (VSCodeSettings, error) {
-	var settings VSCodeSettings
-	// We can't do normal JSON decode, since the file may contain
-	// comments (which makes it non-standard/invalid JSON). We use 'jsonc' library
-	// for transforming the input into suitable, valid JSON.
-	err := json.Unmarshal(jsonc.ToJSON(inputString), &settings)
-	if err != nil {
-		return VSCodeSettings{}, err
-	}
-	return settings, nil
-}
-
-// FormatCMakeConfigureSettings produces a list of "-DKEY=VALUE" arguments
-// from the configure settings, suitable for passing to CMake program.
-func (settings VSCodeSettings) FormatCMakeConfigureSettings() []string {
-	var args []string
-	for key, value := range settings.CMakeConfigureSettings {
-		//fmt.Println(key, value)
-		args = append(args, fmt.Sprintf("-D%s=%s", key, shellescape.Quote(value)))
-	}
-	// golang iterates map items in random order; this should ensure deterministic results.
-	sort.Strings(args)
-	return args
-}
-
-// CollectCLIArgs builds a complete set of CMake command line arguments from
-// all known information.
-func (settings VSCodeSettings) CollectCLIArgs(argv ...string) []string {
-
-	var allArgs []string
-	allArgs = append(allArgs, settings.FormatCMakeConfigureSettings()...)
-	allArgs = append(allArgs, settings.CMakeConfigureArguments...)
-	allArgs = append(allArgs, argv...)
-	return allArgs
-}
-
-// RunCMakeConfigure run CMake configuration command using the given settings.
-func RunCMakeConfigure(settings VSCodeSettings, dryRun bool) int {
-
-	cmd := exec.Command("cmake", settings.CollectCLIArgs(os.Args[1:]...)...)
-
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	cmd.Stdin = os.Stdin
-
-	fmt.Printf("Running command:\n\t%v\n\n", strings.Join(cmd.Args, " "))
-
-	if dryRun {
-		return 0
-	}
-
-	if res := cmd.Run(); res != nil {
-		fmt.Printf("error: %v\n", res)
-	}
-
-	return cmd.ProcessState.ExitCode()
-}
-
 // GetEnvOrDefault returns environment variable described by 'key', or fallback
 // if the given key does not exist (or is empty).
 func GetEnvOrDefault(key string, fallback string) string {
@@ -95,6 +17,22 @@ func GetEnvOrDefault(key string, fallback string) string {
 	return fallback
 }
 
+// GetEnvAsBool returns false if the environment variable described by 'key'
+// does not exist, or is set to one of "0"/"false" (case-insensitive).
+// Any other value -- including an existing but empty variable -- is true.
+func GetEnvAsBool(key string) bool {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return false
+	}
+	switch strings.ToLower(value) {
+	case "0", "false":
+		return false
+	default:
+		return true
+	}
+}
+
 var helpText = `==========
 
 %[1]s:
@@ -118,6 +56,51 @@ var helpText = `==========
 
 	Of course, a combination of these environment variables should work as expected.
 
+	Alternatively, settings may be sourced from a CMakePresets.json / CMakeUserPresets.json
+	file instead of (or in addition to) .vscode/settings.json:
+
+		$ env VCC_SOURCE=presets VCC_PRESET=my-preset %[1]s
+
+		$ env VCC_SOURCE=merged VCC_PRESET=my-preset %[1]s
+
+	VCC_SOURCE accepts "vscode" (default), "presets", or "merged". VCC_CMAKE_PRESETS
+	overrides the CMakePresets.json path, which defaults to $PWD/CMakePresets.json.
+
+	A compiler kit and/or build variant may also be selected, sourced from
+	cmake-tools-kits.json and cmake-variants.json respectively (paths
+	overridable via VCC_CMAKE_KITS / VCC_CMAKE_VARIANTS):
+
+		$ env VCC_KIT="GCC 9.3.0" VCC_VARIANT=debug+static %[1]s -B mybuild .
+
+	The kit's compilers/toolchainFile/cmakeSettings and the variant's
+	buildType/settings are layered in as defaults, underneath whatever is set
+	via cmake.configureSettings in settings.json or a preset.
+
+	settings.json can optionally be validated against a JSON Schema before use:
+
+		$ env VCC_VALIDATE=strict %[1]s -B mybuild .
+
+	VCC_VALIDATE accepts "off" (default), "warn" (print violations to stderr
+	but continue), or "strict" (fail with the offending JSON pointers).
+
+	Besides configuring, the program can also drive the rest of the CMake project
+	lifecycle by chaining subcommand names on the command line:
+
+		$ %[1]s configure build test
+		$ %[1]s install
+
+	"configure" (the default when no subcommand is given) behaves as described
+	above, "build" runs "cmake --build" with cmake.buildArgs, "test" runs ctest
+	with cmake.ctestArgs, and "install" runs "cmake --install" honoring
+	cmake.installPrefix. All of them share the same binary directory, taken from
+	an explicit "-B" argument if given, otherwise from cmake.buildDirectory. The
+	chain stops at the first subcommand that fails.
+
+	When VCC_SOURCE is "presets" or "merged", a buildPresets/testPresets entry
+	sharing VCC_PRESET's name supplies "build"/"test"'s arguments instead of
+	cmake.buildArgs/cmake.ctestArgs; if no such entry exists, those subcommands
+	fall back to cmake.buildArgs/cmake.ctestArgs as usual.
+
 ==========
 
 `
@@ -126,21 +109,123 @@ func showHelp() {
 	fmt.Printf(helpText, os.Args[0])
 }
 
+// subcommands is the set of cmake-lifecycle steps vcc knows how to run.
+var subcommands = map[string]bool{
+	"configure": true,
+	"build":     true,
+	"test":      true,
+	"install":   true,
+}
+
+// parseSubcommands splits args into the requested subcommand chain and the
+// remaining passthrough arguments (forwarded to the "configure" step, for
+// backwards compatibility with invocations like "vcc -B mybuild ."). When
+// args contains no recognized subcommand name, the chain defaults to
+// ["configure"] and every argument is treated as passthrough.
+func parseSubcommands(args []string) (chain []string, passthrough []string) {
+	for _, arg := range args {
+		if subcommands[arg] {
+			chain = append(chain, arg)
+		} else {
+			passthrough = append(passthrough, arg)
+		}
+	}
+	if len(chain) == 0 {
+		chain = []string{"configure"}
+	}
+	return chain, passthrough
+}
+
+// resolveBinaryDir returns the build directory to thread through every
+// subcommand: an explicit "-B"/"-B<dir>" passthrough argument takes
+// precedence over cmake.buildDirectory (which itself may have come from a
+// CMakePresets.json preset or .vscode/settings.json).
+func resolveBinaryDir(settings cmakecfg.VSCodeSettings, passthrough []string) string {
+	for i, arg := range passthrough {
+		if arg == "-B" && i+1 < len(passthrough) {
+			return passthrough[i+1]
+		}
+		if strings.HasPrefix(arg, "-B") && len(arg) > len("-B") {
+			return strings.TrimPrefix(arg, "-B")
+		}
+	}
+	return settings.CMakeBuildDirectory
+}
+
 func main() {
 	inFile := GetEnvOrDefault("VCC_VSCODE_SETTINGS", ".vscode/settings.json")
+	presetsFile := GetEnvOrDefault("VCC_CMAKE_PRESETS", "CMakePresets.json")
+	presetName := os.Getenv("VCC_PRESET")
+	source := GetEnvOrDefault("VCC_SOURCE", "vscode")
 	dryRun := GetEnvOrDefault("VCC_DRY_RUN", "FALSE") != "FALSE"
 
 	if len(os.Args) >= 2 && (os.Args[1] == "-h" || os.Args[1] == "--help") {
 		showHelp()
 	}
 
-	settings, err := ReadVSCodeSettings(inFile)
+	ctx := cmakecfg.ExpandContext{
+		WorkspaceFolder: GetEnvOrDefault("VCC_WORKSPACE_FOLDER", ""),
+		AllowUnresolved: GetEnvAsBool("VCC_ALLOW_UNRESOLVED"),
+	}
+
+	validateMode := GetEnvOrDefault("VCC_VALIDATE", "off")
+
+	settings, err := cmakecfg.LoadSettings(inFile, presetsFile, presetName, source, ctx, validateMode)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	// A selected kit and/or variant provide defaults (compilers, toolchain
+	// file, build type, ...) that cmake.configureSettings/cmake.environment
+	// from settings.json or a preset are free to override.
+	kitsFile := GetEnvOrDefault("VCC_CMAKE_KITS", "cmake-tools-kits.json")
+	variantsFile := GetEnvOrDefault("VCC_CMAKE_VARIANTS", "cmake-variants.json")
+	kitVariantSettings, err := cmakecfg.LoadKitAndVariantSettings(kitsFile, os.Getenv("VCC_KIT"), variantsFile, os.Getenv("VCC_VARIANT"))
 	if err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
+	settings = cmakecfg.MergeVSCodeSettings(kitVariantSettings, settings)
+
+	// A buildPresets/testPresets entry sharing the active configure preset's
+	// name supplies the "build"/"test" subcommands' arguments, taking
+	// precedence over cmake.buildArgs/cmake.ctestArgs. Such an entry is
+	// optional, so its absence is not an error.
+	if presetName != "" && source != "vscode" {
+		if buildArgs, err := cmakecfg.ReadCMakeBuildPresetArgs(presetsFile, presetName); err == nil {
+			settings.CMakeBuildArgs = buildArgs
+		}
+		if testArgs, err := cmakecfg.ReadCMakeTestPresetArgs(presetsFile, presetName); err == nil {
+			settings.CMakeTestArgs = testArgs
+		}
+	}
+
+	// cmake.environment applies to the whole lifecycle, not just configure,
+	// so every subcommand's RunTool call inherits it via os.Environ().
+	for key, value := range settings.CMakeEnvironment {
+		os.Setenv(key, value)
+	}
 
-	retcode := RunCMakeConfigure(settings, dryRun)
+	chain, passthrough := parseSubcommands(os.Args[1:])
+	binaryDir := resolveBinaryDir(settings, passthrough)
+
+	retcode := 0
+	for _, step := range chain {
+		switch step {
+		case "configure":
+			retcode = cmakecfg.RunCMakeConfigure(settings, dryRun, passthrough)
+		case "build":
+			retcode = cmakecfg.RunCMakeBuild(settings, binaryDir, dryRun)
+		case "test":
+			retcode = cmakecfg.RunCTest(settings, binaryDir, dryRun)
+		case "install":
+			retcode = cmakecfg.RunCMakeInstall(settings, binaryDir, dryRun)
+		}
+		if retcode != 0 {
+			break
+		}
+	}
 
 	os.Exit(retcode)
 }
@@ -19,85 +19,9 @@ import (
 	"os"
 	"reflect"
 	"testing"
-)
-
-// Well, technically this is not valid "JSON" but.. whatever
-var exampleSettingsJSON = `
-{
-    "editor.formatOnSave": true,
-    "cmake.configureOnOpen": true,
-    "cmake.configureArgs": [
-        "-GNinja"
-    ],
-	// A comment here
-    "cmake.configureSettings": {
-		"CMAKE_CXX_COMPILER": "clang++",
-		"CMAKE_CXX_FLAGS_INIT": "-fdiagnostics-color=always -O3",
-		"CMAKE_CXX_STANDARD_REQUIRED": "ON", // stupid CMake does not put -std= flag on the command line with GCC, but on Clang it is present
-		"CMAKE_CXX_STANDARD": "17"
-    },
-    "cmake.ctestArgs": []
-	// and rest of your settings.json
-}
-`
-
-func TestParseVSCodeSettings(t *testing.T) {
-	settings, err := ParseVSCodeSettings([]byte(exampleSettingsJSON))
-	if err != nil {
-		t.Fatalf("VSCode settings parsing failed: %q", err)
-	}
-
-	t.Run("Test that cmake.configureArgs is parsed correctly", func(t *testing.T) {
-		expectedArguments := []string{"-GNinja"}
-		if !reflect.DeepEqual(settings.CMakeConfigureArguments, expectedArguments) {
-			t.Fatalf("Expected CMakeConfigureArguments: %v, got: %v",
-				expectedArguments, settings.CMakeConfigureArguments)
-		}
-	})
-
-	t.Run("Test that cmake.configureSettings is parsed correctly", func(t *testing.T) {
-		expectedSettings := map[string]string{
-			"CMAKE_CXX_COMPILER":          "clang++",
-			"CMAKE_CXX_FLAGS_INIT":        "-fdiagnostics-color=always -O3",
-			"CMAKE_CXX_STANDARD":          "17",
-			"CMAKE_CXX_STANDARD_REQUIRED": "ON",
-		}
-
-		if !reflect.DeepEqual(expectedSettings, settings.CMakeConfigureSettings) {
-			t.Fatalf("Expected CMakeConfigureSettings:\n\t%v, got:\n\t%v",
-				settings.CMakeConfigureSettings, expectedSettings)
-		}
-	})
-
-	t.Run("Test that cmake.configureSettings are formatted properly", func(t *testing.T) {
-		formatted := settings.FormatCMakeConfigureSettings()
-		// NOTE: The program sorts the keys since maps are iterated in random order
-		expected := []string{
-			"-DCMAKE_CXX_COMPILER=clang++",
-			"-DCMAKE_CXX_FLAGS_INIT='-fdiagnostics-color=always -O3'",
-			"-DCMAKE_CXX_STANDARD=17",
-			"-DCMAKE_CXX_STANDARD_REQUIRED=ON",
-		}
-		if !reflect.DeepEqual(formatted, expected) {
-			t.Fatalf("Expected formatted:\n\t%v,\ngot:\n\t%v", expected, formatted)
-		}
-	})
-	t.Run("Test that computed CLI arguments are correct", func(t *testing.T) {
-		cliArgs := settings.CollectCLIArgs("-h")
-		expected := []string{
-			"-DCMAKE_CXX_COMPILER=clang++",
-			"-DCMAKE_CXX_FLAGS_INIT='-fdiagnostics-color=always -O3'",
-			"-DCMAKE_CXX_STANDARD=17",
-			"-DCMAKE_CXX_STANDARD_REQUIRED=ON",
-			"-GNinja",
-			"-h",
-		}
 
-		if !reflect.DeepEqual(expected, cliArgs) {
-			t.Fatalf("Expected command line:\n\t%v,\ngot:\n\t%v", expected, cliArgs)
-		}
-	})
-}
+	"github.com/MawKKe/vscode-cmake-configure/pkg/cmakecfg"
+)
 
 func TestGetEnvVarOrDefault(t *testing.T) {
 	t.Run("Test that nonexistent env variable produces default value", func(t *testing.T) {
@@ -152,3 +76,58 @@ func TestGetEnvAsBool(t *testing.T) {
 
 	})
 }
+
+func TestParseSubcommands(t *testing.T) {
+	t.Run("Test that no recognized subcommand defaults to configure with full passthrough", func(t *testing.T) {
+		chain, passthrough := parseSubcommands([]string{"-B", "mybuild", "."})
+		if !reflect.DeepEqual(chain, []string{"configure"}) {
+			t.Fatalf("Expected [configure], got %v", chain)
+		}
+		if !reflect.DeepEqual(passthrough, []string{"-B", "mybuild", "."}) {
+			t.Fatalf("Expected passthrough to be unchanged, got %v", passthrough)
+		}
+	})
+
+	t.Run("Test that recognized subcommands are chained in order", func(t *testing.T) {
+		chain, passthrough := parseSubcommands([]string{"configure", "build", "test"})
+		if !reflect.DeepEqual(chain, []string{"configure", "build", "test"}) {
+			t.Fatalf("Expected [configure build test], got %v", chain)
+		}
+		if len(passthrough) != 0 {
+			t.Fatalf("Expected no passthrough args, got %v", passthrough)
+		}
+	})
+
+	t.Run("Test that unrecognized args alongside subcommands become passthrough", func(t *testing.T) {
+		chain, passthrough := parseSubcommands([]string{"-B", "mybuild", "configure"})
+		if !reflect.DeepEqual(chain, []string{"configure"}) {
+			t.Fatalf("Expected [configure], got %v", chain)
+		}
+		if !reflect.DeepEqual(passthrough, []string{"-B", "mybuild"}) {
+			t.Fatalf("Expected [-B mybuild], got %v", passthrough)
+		}
+	})
+}
+
+func TestResolveBinaryDir(t *testing.T) {
+	t.Run("Test that cmake.buildDirectory is used when no -B is given", func(t *testing.T) {
+		settings := cmakecfg.VSCodeSettings{CMakeBuildDirectory: "build-from-settings"}
+		if got := resolveBinaryDir(settings, nil); got != "build-from-settings" {
+			t.Fatalf("Expected %q, got %q", "build-from-settings", got)
+		}
+	})
+
+	t.Run("Test that a separate -B argument takes precedence", func(t *testing.T) {
+		settings := cmakecfg.VSCodeSettings{CMakeBuildDirectory: "build-from-settings"}
+		if got := resolveBinaryDir(settings, []string{"-B", "build-from-cli"}); got != "build-from-cli" {
+			t.Fatalf("Expected %q, got %q", "build-from-cli", got)
+		}
+	})
+
+	t.Run("Test that an attached -Bdir argument takes precedence", func(t *testing.T) {
+		settings := cmakecfg.VSCodeSettings{CMakeBuildDirectory: "build-from-settings"}
+		if got := resolveBinaryDir(settings, []string{"-Bbuild-from-cli"}); got != "build-from-cli" {
+			t.Fatalf("Expected %q, got %q", "build-from-cli", got)
+		}
+	})
+}
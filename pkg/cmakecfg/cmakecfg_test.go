@@ -0,0 +1,605 @@
+// Copyright 2022 Markus Holmström (MawKKe) markus@mawkke.fi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmakecfg
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+// Well, technically this is not valid "JSON" but.. whatever
+var exampleSettingsJSON = `
+{
+    "editor.formatOnSave": true,
+    "cmake.configureOnOpen": true,
+    "cmake.configureArgs": [
+        "-GNinja"
+    ],
+	// A comment here
+    "cmake.configureSettings": {
+		"CMAKE_CXX_COMPILER": "clang++",
+		"CMAKE_CXX_FLAGS_INIT": "-fdiagnostics-color=always -O3",
+		"CMAKE_CXX_STANDARD_REQUIRED": "ON", // stupid CMake does not put -std= flag on the command line with GCC, but on Clang it is present
+		"CMAKE_CXX_STANDARD": "17"
+    },
+    "cmake.ctestArgs": []
+	// and rest of your settings.json
+}
+`
+
+func TestParseVSCodeSettings(t *testing.T) {
+	settings, err := ParseVSCodeSettings([]byte(exampleSettingsJSON), "off")
+	if err != nil {
+		t.Fatalf("VSCode settings parsing failed: %q", err)
+	}
+
+	t.Run("Test that cmake.configureArgs is parsed correctly", func(t *testing.T) {
+		expectedArguments := []string{"-GNinja"}
+		if !reflect.DeepEqual(settings.CMakeConfigureArguments, expectedArguments) {
+			t.Fatalf("Expected CMakeConfigureArguments: %v, got: %v",
+				expectedArguments, settings.CMakeConfigureArguments)
+		}
+	})
+
+	t.Run("Test that cmake.configureSettings is parsed correctly", func(t *testing.T) {
+		expectedSettings := map[string]ConfigureValue{
+			"CMAKE_CXX_COMPILER":          {Value: "clang++"},
+			"CMAKE_CXX_FLAGS_INIT":        {Value: "-fdiagnostics-color=always -O3"},
+			"CMAKE_CXX_STANDARD":          {Value: "17"},
+			"CMAKE_CXX_STANDARD_REQUIRED": {Value: "ON"},
+		}
+
+		if !reflect.DeepEqual(expectedSettings, settings.CMakeConfigureSettings) {
+			t.Fatalf("Expected CMakeConfigureSettings:\n\t%v, got:\n\t%v",
+				expectedSettings, settings.CMakeConfigureSettings)
+		}
+	})
+
+	t.Run("Test that cmake.configureSettings are formatted properly", func(t *testing.T) {
+		formatted := settings.FormatCMakeConfigureSettings()
+		// NOTE: The program sorts the keys since maps are iterated in random order
+		expected := []string{
+			"-DCMAKE_CXX_COMPILER=clang++",
+			"-DCMAKE_CXX_FLAGS_INIT='-fdiagnostics-color=always -O3'",
+			"-DCMAKE_CXX_STANDARD=17",
+			"-DCMAKE_CXX_STANDARD_REQUIRED=ON",
+		}
+		if !reflect.DeepEqual(formatted, expected) {
+			t.Fatalf("Expected formatted:\n\t%v,\ngot:\n\t%v", expected, formatted)
+		}
+	})
+	t.Run("Test that computed CLI arguments are correct", func(t *testing.T) {
+		cliArgs := settings.CollectCLIArgs("-h")
+		expected := []string{
+			"-DCMAKE_CXX_COMPILER=clang++",
+			"-DCMAKE_CXX_FLAGS_INIT='-fdiagnostics-color=always -O3'",
+			"-DCMAKE_CXX_STANDARD=17",
+			"-DCMAKE_CXX_STANDARD_REQUIRED=ON",
+			"-GNinja",
+			"-h",
+		}
+
+		if !reflect.DeepEqual(expected, cliArgs) {
+			t.Fatalf("Expected command line:\n\t%v,\ngot:\n\t%v", expected, cliArgs)
+		}
+	})
+	t.Run("Test that an explicit -B in argv suppresses cmake.buildDirectory", func(t *testing.T) {
+		settings := VSCodeSettings{CMakeBuildDirectory: "build-from-settings"}
+
+		cliArgs := settings.CollectCLIArgs("-B", "mybuild", ".")
+		expected := []string{"-B", "mybuild", "."}
+		if !reflect.DeepEqual(expected, cliArgs) {
+			t.Fatalf("Expected command line:\n\t%v,\ngot:\n\t%v", expected, cliArgs)
+		}
+
+		cliArgs = settings.CollectCLIArgs("-Bmybuild", ".")
+		expected = []string{"-Bmybuild", "."}
+		if !reflect.DeepEqual(expected, cliArgs) {
+			t.Fatalf("Expected command line:\n\t%v,\ngot:\n\t%v", expected, cliArgs)
+		}
+
+		cliArgs = settings.CollectCLIArgs(".")
+		expected = []string{"-B", "build-from-settings", "."}
+		if !reflect.DeepEqual(expected, cliArgs) {
+			t.Fatalf("Expected command line:\n\t%v,\ngot:\n\t%v", expected, cliArgs)
+		}
+	})
+}
+
+func TestConfigureValueUnmarshalJSON(t *testing.T) {
+	cases := []struct {
+		name     string
+		input    string
+		expected ConfigureValue
+	}{
+		{"string", `"clang++"`, ConfigureValue{Value: "clang++"}},
+		{"bool true", `true`, ConfigureValue{CMakeType: "BOOL", Value: "ON"}},
+		{"bool false", `false`, ConfigureValue{CMakeType: "BOOL", Value: "OFF"}},
+		{"integer", `17`, ConfigureValue{Value: "17"}},
+		{"float", `1.5`, ConfigureValue{Value: "1.5"}},
+		{"array", `[1, 2, 3]`, ConfigureValue{Value: "1;2;3"}},
+		{"array of strings", `["a", "b"]`, ConfigureValue{Value: "a;b"}},
+		{"typed object", `{"type":"FILEPATH","value":"/x"}`, ConfigureValue{CMakeType: "FILEPATH", Value: "/x"}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var got ConfigureValue
+			if err := json.Unmarshal([]byte(c.input), &got); err != nil {
+				t.Fatalf("UnmarshalJSON failed: %q", err)
+			}
+			if got != c.expected {
+				t.Fatalf("Expected %+v, got %+v", c.expected, got)
+			}
+		})
+	}
+}
+
+func TestFormatCMakeConfigureSettingsTypedValues(t *testing.T) {
+	settings := VSCodeSettings{
+		CMakeConfigureSettings: map[string]ConfigureValue{
+			"ENABLE_TESTS": {CMakeType: "BOOL", Value: "ON"},
+			"TOOLCHAIN":    {CMakeType: "FILEPATH", Value: "/x"},
+			"VERSIONS":     {Value: "1;2;3"},
+		},
+	}
+	formatted := settings.FormatCMakeConfigureSettings()
+	expected := []string{
+		"-DENABLE_TESTS:BOOL=ON",
+		"-DTOOLCHAIN:FILEPATH=/x",
+		"-DVERSIONS='1;2;3'",
+	}
+	if !reflect.DeepEqual(formatted, expected) {
+		t.Fatalf("Expected formatted:\n\t%v,\ngot:\n\t%v", expected, formatted)
+	}
+}
+
+func TestExpandVariables(t *testing.T) {
+	t.Run("Test that workspaceFolder and env variables are resolved", func(t *testing.T) {
+		os.Setenv("VCC_TEST_TOOLCHAIN", "/opt/toolchain.cmake")
+		defer os.Unsetenv("VCC_TEST_TOOLCHAIN")
+
+		settings := VSCodeSettings{
+			CMakeConfigureSettings: map[string]ConfigureValue{
+				"CMAKE_TOOLCHAIN_FILE": {Value: "${env:VCC_TEST_TOOLCHAIN}"},
+			},
+			CMakeConfigureArguments: []string{"-S", "${workspaceFolder}/src"},
+		}
+		ctx := ExpandContext{WorkspaceFolder: "/home/user/project"}
+
+		expanded, err := ExpandVariables(settings, ctx)
+		if err != nil {
+			t.Fatalf("ExpandVariables failed: %q", err)
+		}
+		if got := expanded.CMakeConfigureSettings["CMAKE_TOOLCHAIN_FILE"].Value; got != "/opt/toolchain.cmake" {
+			t.Fatalf("Expected resolved env variable, got %q", got)
+		}
+		if got := expanded.CMakeConfigureArguments[1]; got != "/home/user/project/src" {
+			t.Fatalf("Expected resolved workspaceFolder, got %q", got)
+		}
+	})
+
+	t.Run("Test that workspaceFolderBasename resolves to the base directory name", func(t *testing.T) {
+		settings := VSCodeSettings{CMakeConfigureArguments: []string{"${workspaceFolderBasename}"}}
+		ctx := ExpandContext{WorkspaceFolder: "/home/user/project"}
+
+		expanded, err := ExpandVariables(settings, ctx)
+		if err != nil {
+			t.Fatalf("ExpandVariables failed: %q", err)
+		}
+		if got := expanded.CMakeConfigureArguments[0]; got != "project" {
+			t.Fatalf("Expected %q, got %q", "project", got)
+		}
+	})
+
+	t.Run("Test that config: variables are resolved from raw settings", func(t *testing.T) {
+		settings := VSCodeSettings{CMakeConfigureArguments: []string{"${config:cmake.generator}"}}
+		ctx := ExpandContext{RawSettings: []byte(`{"cmake.generator": "Ninja"}`)}
+
+		expanded, err := ExpandVariables(settings, ctx)
+		if err != nil {
+			t.Fatalf("ExpandVariables failed: %q", err)
+		}
+		if got := expanded.CMakeConfigureArguments[0]; got != "Ninja" {
+			t.Fatalf("Expected %q, got %q", "Ninja", got)
+		}
+	})
+
+	t.Run("Test that an unresolved variable is an error by default", func(t *testing.T) {
+		settings := VSCodeSettings{CMakeConfigureArguments: []string{"${command:cmake.buildKit}"}}
+		if _, err := ExpandVariables(settings, ExpandContext{}); err == nil {
+			t.Fatalf("Expected error for unresolved variable, got nil")
+		}
+	})
+
+	t.Run("Test that an unresolved variable passes through when AllowUnresolved is set", func(t *testing.T) {
+		settings := VSCodeSettings{CMakeConfigureArguments: []string{"${command:cmake.buildKit}"}}
+		ctx := ExpandContext{AllowUnresolved: true}
+
+		expanded, err := ExpandVariables(settings, ctx)
+		if err != nil {
+			t.Fatalf("ExpandVariables failed: %q", err)
+		}
+		if got := expanded.CMakeConfigureArguments[0]; got != "${command:cmake.buildKit}" {
+			t.Fatalf("Expected variable left untouched, got %q", got)
+		}
+	})
+}
+
+var exampleBasePresetJSON = `
+{
+    "version": 3,
+    "configurePresets": [
+        {
+            "name": "base",
+            "hidden": true,
+            "generator": "Ninja",
+            "binaryDir": "${sourceDir}/build",
+            "cacheVariables": {
+                "CMAKE_BUILD_TYPE": "Release"
+            },
+            "environment": {
+                "CC": "clang"
+            }
+        },
+        {
+            "name": "debug",
+            "inherits": "base",
+            "cacheVariables": {
+                "CMAKE_BUILD_TYPE": "Debug",
+                "ENABLE_TESTS": true
+            },
+            "condition": {
+                "type": "equals",
+                "lhs": "${hostSystemName}",
+                "rhs": "` + runtime.GOOS + `"
+            }
+        }
+    ],
+    "buildPresets": [
+        {
+            "name": "base",
+            "hidden": true,
+            "cleanFirst": true
+        },
+        {
+            "name": "debug",
+            "inherits": "base",
+            "configurePreset": "debug",
+            "targets": ["all"],
+            "jobs": 4
+        }
+    ],
+    "testPresets": [
+        {
+            "name": "debug",
+            "configurePreset": "debug",
+            "filter": {
+                "include": {
+                    "name": "unit_.*"
+                }
+            }
+        }
+    ]
+}
+`
+
+func TestResolveConfigurePreset(t *testing.T) {
+	presets, err := ParseCMakePresets([]byte(exampleBasePresetJSON))
+	if err != nil {
+		t.Fatalf("CMakePresets parsing failed: %q", err)
+	}
+
+	t.Run("Test that inherited fields are merged, child wins on conflicts", func(t *testing.T) {
+		resolved, err := presets.ResolveConfigurePreset("debug")
+		if err != nil {
+			t.Fatalf("ResolveConfigurePreset failed: %q", err)
+		}
+		if resolved.Generator != "Ninja" {
+			t.Fatalf("Expected inherited Generator %q, got %q", "Ninja", resolved.Generator)
+		}
+		if resolved.CacheVariables["CMAKE_BUILD_TYPE"].Value != "Debug" {
+			t.Fatalf("Expected overridden CMAKE_BUILD_TYPE %q, got %q", "Debug", resolved.CacheVariables["CMAKE_BUILD_TYPE"].Value)
+		}
+		if resolved.Environment["CC"] != "clang" {
+			t.Fatalf("Expected inherited environment CC %q, got %q", "clang", resolved.Environment["CC"])
+		}
+	})
+
+	t.Run("Test that unknown preset name is an error", func(t *testing.T) {
+		if _, err := presets.ResolveConfigurePreset("nonexistent"); err == nil {
+			t.Fatalf("Expected error for nonexistent preset, got nil")
+		}
+	})
+
+	t.Run("Test that condition evaluates true for the current host", func(t *testing.T) {
+		resolved, err := presets.ResolveConfigurePreset("debug")
+		if err != nil {
+			t.Fatalf("ResolveConfigurePreset failed: %q", err)
+		}
+		ok, err := resolved.EvaluateCondition("/src")
+		if err != nil {
+			t.Fatalf("EvaluateCondition failed: %q", err)
+		}
+		if !ok {
+			t.Fatalf("Expected condition to evaluate true on %s", runtime.GOOS)
+		}
+	})
+
+	t.Run("Test that ToVSCodeSettings expands ${sourceDir}", func(t *testing.T) {
+		resolved, err := presets.ResolveConfigurePreset("debug")
+		if err != nil {
+			t.Fatalf("ResolveConfigurePreset failed: %q", err)
+		}
+		settings := resolved.ToVSCodeSettings("/src")
+		if settings.CMakeBuildDirectory != "/src/build" {
+			t.Fatalf("Expected expanded BinaryDir %q, got %q", "/src/build", settings.CMakeBuildDirectory)
+		}
+	})
+
+	t.Run("Test that ToVSCodeSettings preserves cacheVariables CMake types", func(t *testing.T) {
+		resolved, err := presets.ResolveConfigurePreset("debug")
+		if err != nil {
+			t.Fatalf("ResolveConfigurePreset failed: %q", err)
+		}
+		settings := resolved.ToVSCodeSettings("/src")
+		enableTests := settings.CMakeConfigureSettings["ENABLE_TESTS"]
+		if enableTests != (ConfigureValue{CMakeType: "BOOL", Value: "ON"}) {
+			t.Fatalf("Expected ENABLE_TESTS:BOOL=ON, got %+v", enableTests)
+		}
+	})
+}
+
+func TestResolveBuildAndTestPreset(t *testing.T) {
+	presets, err := ParseCMakePresets([]byte(exampleBasePresetJSON))
+	if err != nil {
+		t.Fatalf("CMakePresets parsing failed: %q", err)
+	}
+
+	t.Run("Test that inherited build preset fields are merged, child wins on conflicts", func(t *testing.T) {
+		resolved, err := presets.ResolveBuildPreset("debug")
+		if err != nil {
+			t.Fatalf("ResolveBuildPreset failed: %q", err)
+		}
+		if !resolved.CleanFirst {
+			t.Fatalf("Expected inherited CleanFirst true")
+		}
+		expectedArgs := []string{"--target", "all", "--clean-first", "-j", "4"}
+		if !reflect.DeepEqual(resolved.ToArgs(), expectedArgs) {
+			t.Fatalf("Expected build preset args:\n\t%v,\ngot:\n\t%v", expectedArgs, resolved.ToArgs())
+		}
+	})
+
+	t.Run("Test that unknown build preset name is an error", func(t *testing.T) {
+		if _, err := presets.ResolveBuildPreset("nonexistent"); err == nil {
+			t.Fatalf("Expected error for nonexistent build preset, got nil")
+		}
+	})
+
+	t.Run("Test that test preset filter becomes a ctest -R argument", func(t *testing.T) {
+		resolved, err := presets.ResolveTestPreset("debug")
+		if err != nil {
+			t.Fatalf("ResolveTestPreset failed: %q", err)
+		}
+		expectedArgs := []string{"-R", "unit_.*"}
+		if !reflect.DeepEqual(resolved.ToArgs(), expectedArgs) {
+			t.Fatalf("Expected test preset args:\n\t%v,\ngot:\n\t%v", expectedArgs, resolved.ToArgs())
+		}
+	})
+}
+
+func TestConfigBuilder(t *testing.T) {
+	t.Run("Test that Define and DefineTyped populate configure settings", func(t *testing.T) {
+		cfg := NewConfig().
+			Define("CMAKE_CXX_COMPILER", "clang++").
+			DefineTyped("CMAKE_TOOLCHAIN_FILE", "FILEPATH", "/x/toolchain.cmake").
+			Generator("Ninja").
+			BinaryDir("build").
+			Env("CC", "clang")
+
+		expectedSettings := map[string]ConfigureValue{
+			"CMAKE_CXX_COMPILER":   {Value: "clang++"},
+			"CMAKE_TOOLCHAIN_FILE": {CMakeType: "FILEPATH", Value: "/x/toolchain.cmake"},
+		}
+		if !reflect.DeepEqual(cfg.settings.CMakeConfigureSettings, expectedSettings) {
+			t.Fatalf("Expected configure settings %+v, got %+v", expectedSettings, cfg.settings.CMakeConfigureSettings)
+		}
+		if cfg.settings.CMakeGenerator != "Ninja" {
+			t.Fatalf("Expected generator %q, got %q", "Ninja", cfg.settings.CMakeGenerator)
+		}
+		if cfg.settings.CMakeBuildDirectory != "build" {
+			t.Fatalf("Expected binary dir %q, got %q", "build", cfg.settings.CMakeBuildDirectory)
+		}
+		if cfg.settings.CMakeEnvironment["CC"] != "clang" {
+			t.Fatalf("Expected CC=clang in environment, got %v", cfg.settings.CMakeEnvironment)
+		}
+	})
+}
+
+var exampleKitsJSON = `
+[
+    {
+        "name": "GCC 9.3.0",
+        "compilers": {
+            "C": "/usr/bin/gcc-9",
+            "CXX": "/usr/bin/g++-9"
+        },
+        "environmentVariables": {
+            "CC": "/usr/bin/gcc-9"
+        }
+    },
+    {
+        "name": "Clang with toolchain",
+        "toolchainFile": "/opt/toolchain.cmake",
+        "cmakeSettings": {
+            "ENABLE_LTO": true
+        }
+    }
+]
+`
+
+func TestKitToVSCodeSettings(t *testing.T) {
+	kits, err := ParseKits([]byte(exampleKitsJSON))
+	if err != nil {
+		t.Fatalf("ParseKits failed: %q", err)
+	}
+
+	t.Run("Test that compilers and environment are translated", func(t *testing.T) {
+		kit, err := FindKit(kits, "GCC 9.3.0")
+		if err != nil {
+			t.Fatalf("FindKit failed: %q", err)
+		}
+		settings := kit.ToVSCodeSettings()
+		if settings.CMakeConfigureSettings["CMAKE_C_COMPILER"].Value != "/usr/bin/gcc-9" {
+			t.Fatalf("Expected CMAKE_C_COMPILER %q, got %q", "/usr/bin/gcc-9", settings.CMakeConfigureSettings["CMAKE_C_COMPILER"].Value)
+		}
+		if settings.CMakeConfigureSettings["CMAKE_CXX_COMPILER"].Value != "/usr/bin/g++-9" {
+			t.Fatalf("Expected CMAKE_CXX_COMPILER %q, got %q", "/usr/bin/g++-9", settings.CMakeConfigureSettings["CMAKE_CXX_COMPILER"].Value)
+		}
+		if settings.CMakeEnvironment["CC"] != "/usr/bin/gcc-9" {
+			t.Fatalf("Expected environment CC %q, got %q", "/usr/bin/gcc-9", settings.CMakeEnvironment["CC"])
+		}
+	})
+
+	t.Run("Test that toolchainFile and cmakeSettings are translated", func(t *testing.T) {
+		kit, err := FindKit(kits, "Clang with toolchain")
+		if err != nil {
+			t.Fatalf("FindKit failed: %q", err)
+		}
+		settings := kit.ToVSCodeSettings()
+		toolchain := settings.CMakeConfigureSettings["CMAKE_TOOLCHAIN_FILE"]
+		if toolchain != (ConfigureValue{CMakeType: "FILEPATH", Value: "/opt/toolchain.cmake"}) {
+			t.Fatalf("Expected typed CMAKE_TOOLCHAIN_FILE, got %+v", toolchain)
+		}
+		lto := settings.CMakeConfigureSettings["ENABLE_LTO"]
+		if lto != (ConfigureValue{CMakeType: "BOOL", Value: "ON"}) {
+			t.Fatalf("Expected ENABLE_LTO:BOOL=ON, got %+v", lto)
+		}
+	})
+
+	t.Run("Test that unknown kit name is an error", func(t *testing.T) {
+		if _, err := FindKit(kits, "nonexistent"); err == nil {
+			t.Fatalf("Expected error for nonexistent kit, got nil")
+		}
+	})
+}
+
+var exampleVariantsJSON = `
+{
+    "buildType": {
+        "default": "debug",
+        "choices": {
+            "debug": {
+                "short": "Debug",
+                "buildType": "Debug"
+            },
+            "release": {
+                "short": "Release",
+                "buildType": "Release"
+            }
+        }
+    },
+    "linkage": {
+        "default": "shared",
+        "choices": {
+            "static": {
+                "short": "Static",
+                "settings": {
+                    "BUILD_SHARED_LIBS": false
+                }
+            },
+            "shared": {
+                "short": "Shared",
+                "settings": {
+                    "BUILD_SHARED_LIBS": true
+                }
+            }
+        }
+    }
+}
+`
+
+func TestSelectVariant(t *testing.T) {
+	variants, err := ParseVariants([]byte(exampleVariantsJSON))
+	if err != nil {
+		t.Fatalf("ParseVariants failed: %q", err)
+	}
+
+	t.Run("Test that choices across axes are merged", func(t *testing.T) {
+		settings, err := variants.SelectVariant("debug+static")
+		if err != nil {
+			t.Fatalf("SelectVariant failed: %q", err)
+		}
+		if settings.CMakeConfigureSettings["CMAKE_BUILD_TYPE"].Value != "Debug" {
+			t.Fatalf("Expected CMAKE_BUILD_TYPE %q, got %q", "Debug", settings.CMakeConfigureSettings["CMAKE_BUILD_TYPE"].Value)
+		}
+		sharedLibs := settings.CMakeConfigureSettings["BUILD_SHARED_LIBS"]
+		if sharedLibs != (ConfigureValue{CMakeType: "BOOL", Value: "OFF"}) {
+			t.Fatalf("Expected BUILD_SHARED_LIBS:BOOL=OFF, got %+v", sharedLibs)
+		}
+	})
+
+	t.Run("Test that an unknown choice name is an error", func(t *testing.T) {
+		if _, err := variants.SelectVariant("debug+nonexistent"); err == nil {
+			t.Fatalf("Expected error for nonexistent choice, got nil")
+		}
+	})
+}
+
+func TestValidateSettings(t *testing.T) {
+	valid := []byte(`{"cmake.generator": "Ninja", "cmake.configureArgs": ["-GNinja"]}`)
+	invalid := []byte(`{"cmake.generator": 3, "cmake.configureArgs": "not-an-array"}`)
+
+	t.Run("Test that mode off skips validation entirely", func(t *testing.T) {
+		if err := ValidateSettings(invalid, "off"); err != nil {
+			t.Fatalf("Expected no error with VCC_VALIDATE=off, got %q", err)
+		}
+	})
+
+	t.Run("Test that valid settings pass strict mode", func(t *testing.T) {
+		if err := ValidateSettings(valid, "strict"); err != nil {
+			t.Fatalf("Expected no error for valid settings, got %q", err)
+		}
+	})
+
+	t.Run("Test that invalid settings fail strict mode with JSON pointers", func(t *testing.T) {
+		err := ValidateSettings(invalid, "strict")
+		if err == nil {
+			t.Fatalf("Expected error for invalid settings, got nil")
+		}
+		var validationErr *ValidationError
+		if !errors.As(err, &validationErr) {
+			t.Fatalf("Expected *ValidationError, got %T: %v", err, err)
+		}
+		if len(validationErr.Issues) == 0 {
+			t.Fatalf("Expected at least one validation issue")
+		}
+	})
+
+	t.Run("Test that invalid settings only warn (and don't fail) in warn mode", func(t *testing.T) {
+		if err := ValidateSettings(invalid, "warn"); err != nil {
+			t.Fatalf("Expected no error with VCC_VALIDATE=warn, got %q", err)
+		}
+	})
+
+	t.Run("Test that an unknown mode is an error", func(t *testing.T) {
+		if err := ValidateSettings(valid, "bogus"); err == nil {
+			t.Fatalf("Expected error for unknown VCC_VALIDATE mode, got nil")
+		}
+	})
+}
@@ -0,0 +1,140 @@
+// Copyright 2022 Markus Holmström (MawKKe) markus@mawkke.fi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmakecfg
+
+import (
+	"context"
+	"os"
+	"os/exec"
+)
+
+// Config is a builder for a single CMake configure invocation, for embedding
+// vcc's settings resolution into other Go programs (build tools, Mage/Task
+// targets, CI helpers) without shelling out to the vcc binary itself.
+//
+// Methods that don't return an error are chainable:
+//
+//	cfg := cmakecfg.NewConfig().Generator("Ninja").Define("CMAKE_BUILD_TYPE", "Release")
+//	result, err := cfg.Configure(context.Background())
+type Config struct {
+	settings VSCodeSettings
+}
+
+// NewConfig returns an empty Config ready for chaining.
+func NewConfig() *Config {
+	return &Config{}
+}
+
+// Define sets an untyped "-DKEY=VALUE" configure setting.
+func (c *Config) Define(key, value string) *Config {
+	c.ensureConfigureSettings()
+	c.settings.CMakeConfigureSettings[key] = ConfigureValue{Value: value}
+	return c
+}
+
+// DefineTyped sets a typed "-DKEY:TYPE=VALUE" configure setting, e.g.
+// DefineTyped("CMAKE_TOOLCHAIN_FILE", "FILEPATH", "/x/toolchain.cmake").
+func (c *Config) DefineTyped(key, cmakeType, value string) *Config {
+	c.ensureConfigureSettings()
+	c.settings.CMakeConfigureSettings[key] = ConfigureValue{CMakeType: cmakeType, Value: value}
+	return c
+}
+
+// Generator sets the CMake generator ("-G").
+func (c *Config) Generator(name string) *Config {
+	c.settings.CMakeGenerator = name
+	return c
+}
+
+// BinaryDir sets the build directory ("-B").
+func (c *Config) BinaryDir(dir string) *Config {
+	c.settings.CMakeBuildDirectory = dir
+	return c
+}
+
+// Env sets an environment variable to pass to the configure subprocess.
+func (c *Config) Env(key, value string) *Config {
+	if c.settings.CMakeEnvironment == nil {
+		c.settings.CMakeEnvironment = map[string]string{}
+	}
+	c.settings.CMakeEnvironment[key] = value
+	return c
+}
+
+// ensureConfigureSettings lazily initializes CMakeConfigureSettings so
+// Define/DefineTyped can be called on a zero-value Config.
+func (c *Config) ensureConfigureSettings() {
+	if c.settings.CMakeConfigureSettings == nil {
+		c.settings.CMakeConfigureSettings = map[string]ConfigureValue{}
+	}
+}
+
+// LoadVSCodeSettings reads path (a .vscode/settings.json-style file) and
+// layers it on top of whatever has already been set on c, with the loaded
+// file's values taking precedence on conflicts.
+func (c *Config) LoadVSCodeSettings(path string) error {
+	settings, err := ReadVSCodeSettings(path, ExpandContext{}, "off")
+	if err != nil {
+		return err
+	}
+	c.settings = MergeVSCodeSettings(c.settings, settings)
+	return nil
+}
+
+// LoadPresets reads path (a CMakePresets.json-style file), resolves preset's
+// inherits chain, and layers the result on top of whatever has already been
+// set on c, with the preset's values taking precedence on conflicts.
+func (c *Config) LoadPresets(path, preset string) error {
+	settings, err := ReadCMakePresetSettings(path, preset)
+	if err != nil {
+		return err
+	}
+	c.settings = MergeVSCodeSettings(c.settings, settings)
+	return nil
+}
+
+// Result describes the outcome of a Config.Configure invocation.
+type Result struct {
+	// Args is the full argv passed to the underlying "cmake" invocation.
+	Args []string
+	// ExitCode is the underlying process's exit code.
+	ExitCode int
+}
+
+// Configure runs "cmake" with the settings accumulated on c, honoring ctx
+// for cancellation/timeouts.
+func (c *Config) Configure(ctx context.Context) (*Result, error) {
+	cmd := exec.CommandContext(ctx, "cmake", c.settings.CollectCLIArgs()...)
+
+	if len(c.settings.CMakeEnvironment) > 0 {
+		env := os.Environ()
+		for key, value := range c.settings.CMakeEnvironment {
+			env = append(env, key+"="+value)
+		}
+		cmd.Env = env
+	}
+
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Stdin = os.Stdin
+
+	result := &Result{Args: cmd.Args}
+
+	err := cmd.Run()
+	if cmd.ProcessState != nil {
+		result.ExitCode = cmd.ProcessState.ExitCode()
+	}
+	return result, err
+}
@@ -0,0 +1,110 @@
+// Copyright 2022 Markus Holmström (MawKKe) markus@mawkke.fi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmakecfg
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ConfigureValue is a single entry of "cmake.configureSettings". The VSCode
+// CMake Tools extension accepts plain strings, booleans, numbers, arrays,
+// and typed objects (`{"type":"FILEPATH","value":"/x"}`) there; ConfigureValue
+// normalizes all of those down to the CMake cache variable type suffix
+// (empty for untyped) and the literal value to put after "=".
+type ConfigureValue struct {
+	// CMakeType is the CMake cache variable type suffix, e.g. "BOOL" or
+	// "FILEPATH", or "" if the value is untyped.
+	CMakeType string
+	// Value is the literal string to place after "-DKEY[:TYPE]=".
+	Value string
+}
+
+// UnmarshalJSON decodes a "cmake.configureSettings" entry, which may be a
+// string, boolean, number, array, or a typed `{"type":..., "value":...}` object.
+func (v *ConfigureValue) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*v = ConfigureValue{Value: s}
+		return nil
+	}
+
+	var b bool
+	if err := json.Unmarshal(data, &b); err == nil {
+		*v = ConfigureValue{CMakeType: "BOOL", Value: boolToCMakeBool(b)}
+		return nil
+	}
+
+	var n float64
+	if err := json.Unmarshal(data, &n); err == nil {
+		if n == float64(int64(n)) {
+			*v = ConfigureValue{Value: strconv.FormatInt(int64(n), 10)}
+		} else {
+			*v = ConfigureValue{Value: strconv.FormatFloat(n, 'g', -1, 64)}
+		}
+		return nil
+	}
+
+	var arr []json.RawMessage
+	if err := json.Unmarshal(data, &arr); err == nil {
+		items := make([]string, len(arr))
+		for i, item := range arr {
+			items[i] = (&ConfigureValue{}).unmarshalScalar(item)
+		}
+		*v = ConfigureValue{Value: strings.Join(items, ";")}
+		return nil
+	}
+
+	var typed struct {
+		Type  string `json:"type"`
+		Value string `json:"value"`
+	}
+	if err := json.Unmarshal(data, &typed); err == nil && typed.Type != "" {
+		*v = ConfigureValue{CMakeType: typed.Type, Value: typed.Value}
+		return nil
+	}
+
+	return fmt.Errorf("cmake.configureSettings: unsupported value %s", string(data))
+}
+
+// unmarshalScalar decodes a single array element of a "cmake.configureSettings"
+// entry down to its literal string representation (CMake joins array entries
+// with ";").
+func (v *ConfigureValue) unmarshalScalar(data []byte) string {
+	if err := v.UnmarshalJSON(data); err != nil {
+		return strings.Trim(string(data), `"`)
+	}
+	return v.Value
+}
+
+// boolToCMakeBool converts a Go bool into the "ON"/"OFF" spelling CMake uses
+// for BOOL cache variables.
+func boolToCMakeBool(b bool) string {
+	if b {
+		return "ON"
+	}
+	return "OFF"
+}
+
+// FlagName returns the "-D" argument name for key, including the CMake type
+// suffix (e.g. "KEY:BOOL") when CMakeType is set.
+func (v ConfigureValue) FlagName(key string) string {
+	if v.CMakeType == "" {
+		return key
+	}
+	return key + ":" + v.CMakeType
+}
@@ -0,0 +1,144 @@
+// Copyright 2022 Markus Holmström (MawKKe) markus@mawkke.fi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmakecfg
+
+// ---- Variable substitution --------------------------------------------
+//
+// Real-world .vscode/settings.json files liberally use VSCode's "${...}"
+// variable syntax inside cmake.configureSettings and cmake.configureArgs.
+// ExpandVariables resolves those references before the values are
+// shell-escaped by FormatCMakeConfigureSettings.
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/tidwall/jsonc"
+)
+
+var variablePattern = regexp.MustCompile(`\$\{([^}]+)\}`)
+
+// ExpandContext carries the information needed to resolve "${...}" variables
+// found in cmake.configureSettings / cmake.configureArgs.
+type ExpandContext struct {
+	// WorkspaceFolder resolves "${workspaceFolder}" / "${workspaceFolderBasename}".
+	WorkspaceFolder string
+	// AllowUnresolved makes unresolvable variables pass through unchanged
+	// instead of producing an error.
+	AllowUnresolved bool
+	// RawSettings is the (jsonc-stripped-able) settings.json contents, used
+	// to resolve "${config:key}" by re-reading the file by key.
+	RawSettings []byte
+}
+
+// ExpandVariables resolves "${...}" variables in settings.CMakeConfigureSettings
+// values and settings.CMakeConfigureArguments, returning a new VSCodeSettings
+// with the substitutions applied.
+func ExpandVariables(settings VSCodeSettings, ctx ExpandContext) (VSCodeSettings, error) {
+	expanded := settings
+
+	configureSettings := make(map[string]ConfigureValue, len(settings.CMakeConfigureSettings))
+	for key, value := range settings.CMakeConfigureSettings {
+		expandedValue, err := expandString(value.Value, ctx)
+		if err != nil {
+			return VSCodeSettings{}, err
+		}
+		configureSettings[key] = ConfigureValue{CMakeType: value.CMakeType, Value: expandedValue}
+	}
+	expanded.CMakeConfigureSettings = configureSettings
+
+	configureArgs := make([]string, len(settings.CMakeConfigureArguments))
+	for i, arg := range settings.CMakeConfigureArguments {
+		expandedArg, err := expandString(arg, ctx)
+		if err != nil {
+			return VSCodeSettings{}, err
+		}
+		configureArgs[i] = expandedArg
+	}
+	expanded.CMakeConfigureArguments = configureArgs
+
+	return expanded, nil
+}
+
+// expandString replaces every "${...}" variable reference in s using ctx. An
+// unresolvable reference is left untouched when ctx.AllowUnresolved is true,
+// and is an error otherwise.
+func expandString(s string, ctx ExpandContext) (string, error) {
+	var firstErr error
+	result := variablePattern.ReplaceAllStringFunc(s, func(match string) string {
+		if firstErr != nil {
+			return match
+		}
+		name := strings.TrimSuffix(strings.TrimPrefix(match, "${"), "}")
+		if value, ok := resolveVariable(name, ctx); ok {
+			return value
+		}
+		if ctx.AllowUnresolved {
+			return match
+		}
+		firstErr = fmt.Errorf("unresolved variable %q (set VCC_ALLOW_UNRESOLVED=1 to ignore)", match)
+		return match
+	})
+	if firstErr != nil {
+		return "", firstErr
+	}
+	return result, nil
+}
+
+// resolveVariable resolves a single variable name (the part between "${" and
+// "}") against ctx. The bool result reports whether it could be resolved.
+func resolveVariable(name string, ctx ExpandContext) (string, bool) {
+	switch {
+	case name == "workspaceFolder":
+		return ctx.WorkspaceFolder, true
+	case name == "workspaceFolderBasename":
+		return filepath.Base(ctx.WorkspaceFolder), true
+	case name == "userHome":
+		home, err := os.UserHomeDir()
+		return home, err == nil
+	case name == "pathSeparator":
+		return string(os.PathSeparator), true
+	case strings.HasPrefix(name, "env:"):
+		return os.LookupEnv(strings.TrimPrefix(name, "env:"))
+	case strings.HasPrefix(name, "config:"):
+		return lookupConfigValue(ctx.RawSettings, strings.TrimPrefix(name, "config:"))
+	default:
+		// "${command:...}" and anything else we don't know how to evaluate.
+		return "", false
+	}
+}
+
+// lookupConfigValue resolves "${config:cmake.someKey}" by re-reading
+// rawSettings and looking up the (flat, dotted) top-level key directly, the
+// same way VSCode's settings.json stores "cmake.*" keys.
+func lookupConfigValue(rawSettings []byte, dottedKey string) (string, bool) {
+	var generic map[string]json.RawMessage
+	if err := json.Unmarshal(jsonc.ToJSON(rawSettings), &generic); err != nil {
+		return "", false
+	}
+	raw, ok := generic[dottedKey]
+	if !ok {
+		return "", false
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s, true
+	}
+	return strings.Trim(string(raw), `"`), true
+}
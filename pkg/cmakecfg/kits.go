@@ -0,0 +1,91 @@
+// Copyright 2022 Markus Holmström (MawKKe) markus@mawkke.fi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmakecfg
+
+// ---- cmake-tools-kits.json support ------------------------------------
+//
+// VSCode's CMake Tools extension records known compiler kits in a
+// "cmake-tools-kits.json" file. We support just the fields relevant to the
+// CMake command line: the C/CXX compilers, an optional toolchain file,
+// environment variables, and arbitrary extra cache settings.
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/tidwall/jsonc"
+)
+
+// Kit is one entry of a "cmake-tools-kits.json" file.
+type Kit struct {
+	Name                 string                    `json:"name"`
+	Compilers            map[string]string         `json:"compilers"`
+	ToolchainFile        string                    `json:"toolchainFile"`
+	EnvironmentVariables map[string]string         `json:"environmentVariables"`
+	CMakeSettings        map[string]ConfigureValue `json:"cmakeSettings"`
+}
+
+// ReadKits reads and parses a "cmake-tools-kits.json"-style file.
+func ReadKits(inputFile string) ([]Kit, error) {
+	contents, err := os.ReadFile(inputFile)
+	if err != nil {
+		return nil, err
+	}
+	return ParseKits(contents)
+}
+
+// ParseKits parses cmake-tools-kits.json contents. Like VSCode's
+// settings.json, these files may contain comments.
+func ParseKits(inputString []byte) ([]Kit, error) {
+	var kits []Kit
+	if err := json.Unmarshal(jsonc.ToJSON(inputString), &kits); err != nil {
+		return nil, err
+	}
+	return kits, nil
+}
+
+// FindKit looks up a kit by name.
+func FindKit(kits []Kit, name string) (Kit, error) {
+	for _, kit := range kits {
+		if kit.Name == name {
+			return kit, nil
+		}
+	}
+	return Kit{}, fmt.Errorf("no kit named %q", name)
+}
+
+// ToVSCodeSettings converts the kit into the VSCodeSettings shape: its
+// compilers become CMAKE_C_COMPILER/CMAKE_CXX_COMPILER, its toolchain file
+// becomes CMAKE_TOOLCHAIN_FILE, and cmakeSettings/environmentVariables are
+// carried over as-is.
+func (kit Kit) ToVSCodeSettings() VSCodeSettings {
+	configureSettings := make(map[string]ConfigureValue, len(kit.CMakeSettings)+3)
+	if c, ok := kit.Compilers["C"]; ok {
+		configureSettings["CMAKE_C_COMPILER"] = ConfigureValue{Value: c}
+	}
+	if cxx, ok := kit.Compilers["CXX"]; ok {
+		configureSettings["CMAKE_CXX_COMPILER"] = ConfigureValue{Value: cxx}
+	}
+	if kit.ToolchainFile != "" {
+		configureSettings["CMAKE_TOOLCHAIN_FILE"] = ConfigureValue{CMakeType: "FILEPATH", Value: kit.ToolchainFile}
+	}
+	configureSettings = mergeConfigureValueMaps(configureSettings, kit.CMakeSettings)
+
+	return VSCodeSettings{
+		CMakeConfigureSettings: configureSettings,
+		CMakeEnvironment:       kit.EnvironmentVariables,
+	}
+}
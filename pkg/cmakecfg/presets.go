@@ -0,0 +1,465 @@
+// Copyright 2022 Markus Holmström (MawKKe) markus@mawkke.fi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmakecfg
+
+// ---- CMakePresets.json support ---------------------------------------
+//
+// CMakePresets.json / CMakeUserPresets.json let a project describe CMake
+// configure invocations declaratively. We implement just enough of the
+// format (inherits chains, cacheVariables, environment, generator,
+// binaryDir, condition) to resolve a named configure preset down to a
+// VSCodeSettings value, so it can flow through the same
+// FormatCMakeConfigureSettings / CollectCLIArgs code path used for
+// .vscode/settings.json.
+//
+// buildPresets and testPresets are also resolved (inherits chains only; CMake's
+// "condition"/"inheritConfigureEnvironment" and most other build/test-only
+// fields are not supported) into extra "cmake --build"/"ctest" arguments. vcc
+// looks up a build/test preset sharing the active configure preset's name
+// (VCC_PRESET); if none exists, the "build"/"test" subcommands fall back to
+// cmake.buildArgs/cmake.ctestArgs as before.
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/tidwall/jsonc"
+)
+
+// stringOrStringSlice decodes a JSON value that may be either a single
+// string or an array of strings, such as the "inherits" preset field.
+type stringOrStringSlice []string
+
+func (s *stringOrStringSlice) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*s = []string{single}
+		return nil
+	}
+	var multi []string
+	if err := json.Unmarshal(data, &multi); err != nil {
+		return err
+	}
+	*s = multi
+	return nil
+}
+
+// PresetCondition is the "condition" block of a CMakePresets.json preset.
+// Only the "equals"/"notEquals" condition types are supported.
+type PresetCondition struct {
+	Type string `json:"type"`
+	Lhs  string `json:"lhs"`
+	Rhs  string `json:"rhs"`
+}
+
+// ConfigurePreset is one entry of the "configurePresets" array in
+// CMakePresets.json / CMakeUserPresets.json.
+type ConfigurePreset struct {
+	Name           string                    `json:"name"`
+	Inherits       stringOrStringSlice       `json:"inherits"`
+	Hidden         bool                      `json:"hidden"`
+	Generator      string                    `json:"generator"`
+	BinaryDir      string                    `json:"binaryDir"`
+	CacheVariables map[string]ConfigureValue `json:"cacheVariables"`
+	Environment    map[string]string         `json:"environment"`
+	Condition      *PresetCondition          `json:"condition"`
+}
+
+// TestFilterInclude is the "filter.include" block of a testPresets entry.
+type TestFilterInclude struct {
+	Name string `json:"name"`
+}
+
+// TestFilter is the "filter" block of a testPresets entry.
+type TestFilter struct {
+	Include *TestFilterInclude `json:"include"`
+}
+
+// BuildPreset is one entry of the "buildPresets" array in CMakePresets.json /
+// CMakeUserPresets.json. Only the fields that map onto "cmake --build"
+// arguments are supported.
+type BuildPreset struct {
+	Name            string              `json:"name"`
+	Inherits        stringOrStringSlice `json:"inherits"`
+	Hidden          bool                `json:"hidden"`
+	ConfigurePreset string              `json:"configurePreset"`
+	Targets         stringOrStringSlice `json:"targets"`
+	CleanFirst      bool                `json:"cleanFirst"`
+	Verbose         bool                `json:"verbose"`
+	Jobs            *int                `json:"jobs"`
+}
+
+// TestPreset is one entry of the "testPresets" array in CMakePresets.json /
+// CMakeUserPresets.json. Only the fields that map onto "ctest" arguments are
+// supported.
+type TestPreset struct {
+	Name            string              `json:"name"`
+	Inherits        stringOrStringSlice `json:"inherits"`
+	Hidden          bool                `json:"hidden"`
+	ConfigurePreset string              `json:"configurePreset"`
+	Filter          *TestFilter         `json:"filter"`
+	Jobs            *int                `json:"jobs"`
+}
+
+// CMakePresets is the root object of a CMakePresets.json / CMakeUserPresets.json file.
+type CMakePresets struct {
+	Version          int               `json:"version"`
+	ConfigurePresets []ConfigurePreset `json:"configurePresets"`
+	BuildPresets     []BuildPreset     `json:"buildPresets"`
+	TestPresets      []TestPreset      `json:"testPresets"`
+}
+
+// ReadCMakePresets reads and parses a CMakePresets.json-style file.
+func ReadCMakePresets(inputFile string) (CMakePresets, error) {
+	contents, err := os.ReadFile(inputFile)
+	if err != nil {
+		return CMakePresets{}, err
+	}
+	return ParseCMakePresets(contents)
+}
+
+// ParseCMakePresets parses CMakePresets.json contents. Like VSCode's
+// settings.json, these files may contain comments.
+func ParseCMakePresets(inputString []byte) (CMakePresets, error) {
+	var presets CMakePresets
+	if err := json.Unmarshal(jsonc.ToJSON(inputString), &presets); err != nil {
+		return CMakePresets{}, err
+	}
+	return presets, nil
+}
+
+// findConfigurePreset looks up a configure preset by name.
+func (presets CMakePresets) findConfigurePreset(name string) (ConfigurePreset, error) {
+	for _, preset := range presets.ConfigurePresets {
+		if preset.Name == name {
+			return preset, nil
+		}
+	}
+	return ConfigurePreset{}, fmt.Errorf("no configure preset named %q", name)
+}
+
+// ResolveConfigurePreset walks the "inherits" chain of the named preset and
+// returns the fully merged result. Scalar fields set on a preset take
+// precedence over the same field inherited from its parents; cacheVariables
+// and environment are merged key-by-key, with the child winning conflicts.
+func (presets CMakePresets) ResolveConfigurePreset(name string) (ConfigurePreset, error) {
+	return presets.resolveConfigurePreset(name, make(map[string]bool))
+}
+
+func (presets CMakePresets) resolveConfigurePreset(name string, visited map[string]bool) (ConfigurePreset, error) {
+	if visited[name] {
+		return ConfigurePreset{}, fmt.Errorf("cycle detected in inherits chain at preset %q", name)
+	}
+	visited[name] = true
+
+	preset, err := presets.findConfigurePreset(name)
+	if err != nil {
+		return ConfigurePreset{}, err
+	}
+
+	merged := ConfigurePreset{CacheVariables: map[string]ConfigureValue{}, Environment: map[string]string{}}
+	for _, parentName := range preset.Inherits {
+		parent, err := presets.resolveConfigurePreset(parentName, visited)
+		if err != nil {
+			return ConfigurePreset{}, err
+		}
+		merged = mergeConfigurePresets(merged, parent)
+	}
+	return mergeConfigurePresets(merged, preset), nil
+}
+
+// mergeConfigurePresets layers "overlay" on top of "base": scalar fields are
+// replaced when set in overlay, and map fields are merged key-by-key.
+func mergeConfigurePresets(base, overlay ConfigurePreset) ConfigurePreset {
+	result := base
+	result.Name = overlay.Name
+	result.Hidden = overlay.Hidden
+	if overlay.Condition != nil {
+		result.Condition = overlay.Condition
+	}
+	if overlay.Generator != "" {
+		result.Generator = overlay.Generator
+	}
+	if overlay.BinaryDir != "" {
+		result.BinaryDir = overlay.BinaryDir
+	}
+	result.CacheVariables = mergeConfigureValueMaps(result.CacheVariables, overlay.CacheVariables)
+	result.Environment = mergeStringMaps(result.Environment, overlay.Environment)
+	return result
+}
+
+// findBuildPreset looks up a build preset by name.
+func (presets CMakePresets) findBuildPreset(name string) (BuildPreset, error) {
+	for _, preset := range presets.BuildPresets {
+		if preset.Name == name {
+			return preset, nil
+		}
+	}
+	return BuildPreset{}, fmt.Errorf("no build preset named %q", name)
+}
+
+// ResolveBuildPreset walks the "inherits" chain of the named build preset
+// and returns the fully merged result, analogous to ResolveConfigurePreset.
+func (presets CMakePresets) ResolveBuildPreset(name string) (BuildPreset, error) {
+	return presets.resolveBuildPreset(name, make(map[string]bool))
+}
+
+func (presets CMakePresets) resolveBuildPreset(name string, visited map[string]bool) (BuildPreset, error) {
+	if visited[name] {
+		return BuildPreset{}, fmt.Errorf("cycle detected in inherits chain at build preset %q", name)
+	}
+	visited[name] = true
+
+	preset, err := presets.findBuildPreset(name)
+	if err != nil {
+		return BuildPreset{}, err
+	}
+
+	var merged BuildPreset
+	for _, parentName := range preset.Inherits {
+		parent, err := presets.resolveBuildPreset(parentName, visited)
+		if err != nil {
+			return BuildPreset{}, err
+		}
+		merged = mergeBuildPresets(merged, parent)
+	}
+	return mergeBuildPresets(merged, preset), nil
+}
+
+// mergeBuildPresets layers "overlay" on top of "base": scalar fields are
+// replaced when set in overlay, and Targets is replaced outright (CMake
+// itself does not merge build preset targets across an inherits chain).
+func mergeBuildPresets(base, overlay BuildPreset) BuildPreset {
+	result := base
+	result.Name = overlay.Name
+	result.Hidden = overlay.Hidden
+	if overlay.ConfigurePreset != "" {
+		result.ConfigurePreset = overlay.ConfigurePreset
+	}
+	if len(overlay.Targets) > 0 {
+		result.Targets = overlay.Targets
+	}
+	if overlay.CleanFirst {
+		result.CleanFirst = overlay.CleanFirst
+	}
+	if overlay.Verbose {
+		result.Verbose = overlay.Verbose
+	}
+	if overlay.Jobs != nil {
+		result.Jobs = overlay.Jobs
+	}
+	return result
+}
+
+// ToArgs converts the resolved build preset into the extra "cmake --build"
+// arguments it specifies, beyond the "--build <dir>" that vcc supplies
+// itself from the resolved build directory ("-B" or cmake.buildDirectory).
+func (preset BuildPreset) ToArgs() []string {
+	var args []string
+	for _, target := range preset.Targets {
+		args = append(args, "--target", target)
+	}
+	if preset.CleanFirst {
+		args = append(args, "--clean-first")
+	}
+	if preset.Verbose {
+		args = append(args, "--verbose")
+	}
+	if preset.Jobs != nil {
+		args = append(args, "-j", strconv.Itoa(*preset.Jobs))
+	}
+	return args
+}
+
+// findTestPreset looks up a test preset by name.
+func (presets CMakePresets) findTestPreset(name string) (TestPreset, error) {
+	for _, preset := range presets.TestPresets {
+		if preset.Name == name {
+			return preset, nil
+		}
+	}
+	return TestPreset{}, fmt.Errorf("no test preset named %q", name)
+}
+
+// ResolveTestPreset walks the "inherits" chain of the named test preset and
+// returns the fully merged result, analogous to ResolveConfigurePreset.
+func (presets CMakePresets) ResolveTestPreset(name string) (TestPreset, error) {
+	return presets.resolveTestPreset(name, make(map[string]bool))
+}
+
+func (presets CMakePresets) resolveTestPreset(name string, visited map[string]bool) (TestPreset, error) {
+	if visited[name] {
+		return TestPreset{}, fmt.Errorf("cycle detected in inherits chain at test preset %q", name)
+	}
+	visited[name] = true
+
+	preset, err := presets.findTestPreset(name)
+	if err != nil {
+		return TestPreset{}, err
+	}
+
+	var merged TestPreset
+	for _, parentName := range preset.Inherits {
+		parent, err := presets.resolveTestPreset(parentName, visited)
+		if err != nil {
+			return TestPreset{}, err
+		}
+		merged = mergeTestPresets(merged, parent)
+	}
+	return mergeTestPresets(merged, preset), nil
+}
+
+// mergeTestPresets layers "overlay" on top of "base": scalar fields are
+// replaced when set in overlay.
+func mergeTestPresets(base, overlay TestPreset) TestPreset {
+	result := base
+	result.Name = overlay.Name
+	result.Hidden = overlay.Hidden
+	if overlay.ConfigurePreset != "" {
+		result.ConfigurePreset = overlay.ConfigurePreset
+	}
+	if overlay.Filter != nil {
+		result.Filter = overlay.Filter
+	}
+	if overlay.Jobs != nil {
+		result.Jobs = overlay.Jobs
+	}
+	return result
+}
+
+// ToArgs converts the resolved test preset into the extra "ctest" arguments
+// it specifies, beyond the "--test-dir <dir>" that vcc supplies itself from
+// the resolved build directory ("-B" or cmake.buildDirectory).
+func (preset TestPreset) ToArgs() []string {
+	var args []string
+	if preset.Filter != nil && preset.Filter.Include != nil && preset.Filter.Include.Name != "" {
+		args = append(args, "-R", preset.Filter.Include.Name)
+	}
+	if preset.Jobs != nil {
+		args = append(args, "-j", strconv.Itoa(*preset.Jobs))
+	}
+	return args
+}
+
+// expandPresetMacros resolves the small subset of CMakePresets macros
+// ("${sourceDir}", "${presetName}", "${hostSystemName}") needed to evaluate
+// "condition" blocks and common cacheVariables/environment values.
+func expandPresetMacros(s, sourceDir, presetName string) string {
+	replacer := strings.NewReplacer(
+		"${sourceDir}", sourceDir,
+		"${presetName}", presetName,
+		"${hostSystemName}", runtime.GOOS,
+	)
+	return replacer.Replace(s)
+}
+
+// EvaluateCondition reports whether the preset's "condition" block (if any)
+// allows the preset to be used. A preset without a condition is always usable.
+func (preset ConfigurePreset) EvaluateCondition(sourceDir string) (bool, error) {
+	if preset.Condition == nil {
+		return true, nil
+	}
+	lhs := expandPresetMacros(preset.Condition.Lhs, sourceDir, preset.Name)
+	rhs := expandPresetMacros(preset.Condition.Rhs, sourceDir, preset.Name)
+	switch preset.Condition.Type {
+	case "equals":
+		return lhs == rhs, nil
+	case "notEquals":
+		return lhs != rhs, nil
+	default:
+		return false, fmt.Errorf("unsupported condition type %q", preset.Condition.Type)
+	}
+}
+
+// ToVSCodeSettings converts a resolved ConfigurePreset into the VSCodeSettings
+// shape so it can flow through the existing FormatCMakeConfigureSettings /
+// CollectCLIArgs code path.
+func (preset ConfigurePreset) ToVSCodeSettings(sourceDir string) VSCodeSettings {
+	configureSettings := make(map[string]ConfigureValue, len(preset.CacheVariables))
+	for key, value := range preset.CacheVariables {
+		configureSettings[key] = ConfigureValue{
+			CMakeType: value.CMakeType,
+			Value:     expandPresetMacros(value.Value, sourceDir, preset.Name),
+		}
+	}
+	environment := make(map[string]string, len(preset.Environment))
+	for key, value := range preset.Environment {
+		environment[key] = expandPresetMacros(value, sourceDir, preset.Name)
+	}
+	return VSCodeSettings{
+		CMakeConfigureSettings: configureSettings,
+		CMakeGenerator:         preset.Generator,
+		CMakeBuildDirectory:    expandPresetMacros(preset.BinaryDir, sourceDir, preset.Name),
+		CMakeEnvironment:       environment,
+	}
+}
+
+// ReadCMakePresetSettings reads presetsFile and resolves presetName (applying
+// inherits and evaluating its condition) into a VSCodeSettings value.
+func ReadCMakePresetSettings(presetsFile, presetName string) (VSCodeSettings, error) {
+	presets, err := ReadCMakePresets(presetsFile)
+	if err != nil {
+		return VSCodeSettings{}, err
+	}
+	resolved, err := presets.ResolveConfigurePreset(presetName)
+	if err != nil {
+		return VSCodeSettings{}, err
+	}
+	sourceDir := filepath.Dir(presetsFile)
+	ok, err := resolved.EvaluateCondition(sourceDir)
+	if err != nil {
+		return VSCodeSettings{}, err
+	}
+	if !ok {
+		return VSCodeSettings{}, fmt.Errorf("configure preset %q is not applicable on this platform", presetName)
+	}
+	return resolved.ToVSCodeSettings(sourceDir), nil
+}
+
+// ReadCMakeBuildPresetArgs reads presetsFile and resolves presetName (applying
+// inherits) into the extra "cmake --build" arguments it specifies. Unlike
+// configure presets, a named build preset is optional: not every
+// CMakePresets.json defines one for a given name.
+func ReadCMakeBuildPresetArgs(presetsFile, presetName string) ([]string, error) {
+	presets, err := ReadCMakePresets(presetsFile)
+	if err != nil {
+		return nil, err
+	}
+	resolved, err := presets.ResolveBuildPreset(presetName)
+	if err != nil {
+		return nil, err
+	}
+	return resolved.ToArgs(), nil
+}
+
+// ReadCMakeTestPresetArgs reads presetsFile and resolves presetName (applying
+// inherits) into the extra "ctest" arguments it specifies. Like build
+// presets, a named test preset is optional.
+func ReadCMakeTestPresetArgs(presetsFile, presetName string) ([]string, error) {
+	presets, err := ReadCMakePresets(presetsFile)
+	if err != nil {
+		return nil, err
+	}
+	resolved, err := presets.ResolveTestPreset(presetName)
+	if err != nil {
+		return nil, err
+	}
+	return resolved.ToArgs(), nil
+}
@@ -0,0 +1,73 @@
+// Copyright 2022 Markus Holmström (MawKKe) markus@mawkke.fi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmakecfg
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// RunTool executes a single command (cmake, ctest, ...) with the given
+// arguments, printing it before running. With dryRun set, the command is
+// printed but not actually executed (and 0 is returned).
+func RunTool(name string, args []string, dryRun bool) int {
+	cmd := exec.Command(name, args...)
+
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Stdin = os.Stdin
+
+	fmt.Printf("Running command:\n\t%v\n\n", strings.Join(cmd.Args, " "))
+
+	if dryRun {
+		return 0
+	}
+
+	if res := cmd.Run(); res != nil {
+		fmt.Printf("error: %v\n", res)
+	}
+
+	return cmd.ProcessState.ExitCode()
+}
+
+// RunCMakeConfigure runs the CMake configure step using the given settings,
+// appending extraArgs (e.g. the program's own trailing CLI arguments) last.
+func RunCMakeConfigure(settings VSCodeSettings, dryRun bool, extraArgs []string) int {
+	return RunTool("cmake", settings.CollectCLIArgs(extraArgs...), dryRun)
+}
+
+// RunCMakeBuild runs "cmake --build <binaryDir>" with cmake.buildArgs.
+func RunCMakeBuild(settings VSCodeSettings, binaryDir string, dryRun bool) int {
+	args := append([]string{"--build", binaryDir}, settings.CMakeBuildArgs...)
+	return RunTool("cmake", args, dryRun)
+}
+
+// RunCTest runs ctest against binaryDir with cmake.ctestArgs.
+func RunCTest(settings VSCodeSettings, binaryDir string, dryRun bool) int {
+	args := append([]string{"--test-dir", binaryDir}, settings.CMakeTestArgs...)
+	return RunTool("ctest", args, dryRun)
+}
+
+// RunCMakeInstall runs "cmake --install <binaryDir>", using
+// cmake.installPrefix for "--prefix" when set.
+func RunCMakeInstall(settings VSCodeSettings, binaryDir string, dryRun bool) int {
+	args := []string{"--install", binaryDir}
+	if settings.CMakeInstallPrefix != "" {
+		args = append(args, "--prefix", settings.CMakeInstallPrefix)
+	}
+	return RunTool("cmake", args, dryRun)
+}
@@ -0,0 +1,216 @@
+// Copyright 2022 Markus Holmström (MawKKe) markus@mawkke.fi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmakecfg
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/alessio/shellescape"
+	"github.com/tidwall/jsonc"
+)
+
+// VSCodeSettings is a struct representing VCode settings.json relating to CMake options
+type VSCodeSettings struct {
+	CMakeConfigureSettings  map[string]ConfigureValue `json:"cmake.configureSettings"`
+	CMakeConfigureArguments []string                  `json:"cmake.configureArgs"`
+	CMakeGenerator          string                    `json:"cmake.generator"`
+	CMakeBuildDirectory     string                    `json:"cmake.buildDirectory"`
+	CMakeEnvironment        map[string]string         `json:"cmake.environment"`
+	CMakeBuildArgs          []string                  `json:"cmake.buildArgs"`
+	CMakeTestArgs           []string                  `json:"cmake.ctestArgs"`
+	CMakeInstallPrefix      string                    `json:"cmake.installPrefix"`
+}
+
+// ParseVSCodeSettings extracts CMake -DKEY=VALUE parameters from given input
+// byte slice. validateMode ("strict"/"warn"/"off") gates an optional schema
+// validation pass over the raw JSON; see ValidateSettings.
+func ParseVSCodeSettings(inputString []byte, validateMode string) (VSCodeSettings, error) {
+	// We can't do normal JSON decode, since the file may contain
+	// comments (which makes it non-standard/invalid JSON). We use 'jsonc' library
+	// for transforming the input into suitable, valid JSON.
+	rawJSON := jsonc.ToJSON(inputString)
+
+	if err := ValidateSettings(rawJSON, validateMode); err != nil {
+		return VSCodeSettings{}, err
+	}
+
+	var settings VSCodeSettings
+	if err := json.Unmarshal(rawJSON, &settings); err != nil {
+		return VSCodeSettings{}, err
+	}
+	return settings, nil
+}
+
+// ReadVSCodeSettings reads inputFile and resolves its "${...}" variable
+// references per ctx (see ExpandVariables). ctx.WorkspaceFolder defaults to
+// the directory containing inputFile, and ctx.RawSettings defaults to the
+// file's own contents, when left unset. validateMode is forwarded to
+// ParseVSCodeSettings.
+func ReadVSCodeSettings(inputFile string, ctx ExpandContext, validateMode string) (VSCodeSettings, error) {
+	contents, err := os.ReadFile(inputFile)
+	if err != nil {
+		return VSCodeSettings{}, err
+	}
+	settings, err := ParseVSCodeSettings(contents, validateMode)
+	if err != nil {
+		return VSCodeSettings{}, err
+	}
+	if ctx.WorkspaceFolder == "" {
+		ctx.WorkspaceFolder = filepath.Dir(inputFile)
+	}
+	if ctx.RawSettings == nil {
+		ctx.RawSettings = contents
+	}
+	return ExpandVariables(settings, ctx)
+}
+
+// FormatCMakeConfigureSettings produces a list of "-DKEY=VALUE" arguments
+// ("-DKEY:TYPE=VALUE" for typed values) from the configure settings,
+// suitable for passing to CMake program.
+func (settings VSCodeSettings) FormatCMakeConfigureSettings() []string {
+	var args []string
+	for key, value := range settings.CMakeConfigureSettings {
+		//fmt.Println(key, value)
+		args = append(args, fmt.Sprintf("-D%s=%s", value.FlagName(key), shellescape.Quote(value.Value)))
+	}
+	// golang iterates map items in random order; this should ensure deterministic results.
+	sort.Strings(args)
+	return args
+}
+
+// hasExplicitBinaryDir reports whether argv already contains a "-B"/"-B<dir>"
+// CMake argument, such as when the caller invokes "vcc -B mybuild .".
+func hasExplicitBinaryDir(argv []string) bool {
+	for i, arg := range argv {
+		if arg == "-B" && i+1 < len(argv) {
+			return true
+		}
+		if strings.HasPrefix(arg, "-B") && len(arg) > len("-B") {
+			return true
+		}
+	}
+	return false
+}
+
+// CollectCLIArgs builds a complete set of CMake command line arguments from
+// all known information. If argv already specifies "-B" (e.g. a user running
+// "vcc -B mybuild ."), settings.CMakeBuildDirectory is not also added, since
+// CMake rejects a command line with two "-B" flags.
+func (settings VSCodeSettings) CollectCLIArgs(argv ...string) []string {
+
+	var allArgs []string
+	allArgs = append(allArgs, settings.FormatCMakeConfigureSettings()...)
+	if settings.CMakeGenerator != "" {
+		allArgs = append(allArgs, "-G", settings.CMakeGenerator)
+	}
+	if settings.CMakeBuildDirectory != "" && !hasExplicitBinaryDir(argv) {
+		allArgs = append(allArgs, "-B", settings.CMakeBuildDirectory)
+	}
+	allArgs = append(allArgs, settings.CMakeConfigureArguments...)
+	allArgs = append(allArgs, argv...)
+	return allArgs
+}
+
+// mergeStringMaps returns a new map containing base overlaid with overlay,
+// with overlay values winning on key conflicts.
+func mergeStringMaps(base, overlay map[string]string) map[string]string {
+	merged := make(map[string]string, len(base)+len(overlay))
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range overlay {
+		merged[k] = v
+	}
+	return merged
+}
+
+// mergeConfigureValueMaps returns a new map containing base overlaid with
+// overlay, with overlay values winning on key conflicts.
+func mergeConfigureValueMaps(base, overlay map[string]ConfigureValue) map[string]ConfigureValue {
+	merged := make(map[string]ConfigureValue, len(base)+len(overlay))
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range overlay {
+		merged[k] = v
+	}
+	return merged
+}
+
+// MergeVSCodeSettings layers "overlay" on top of "base": configureSettings
+// and environment are merged key-by-key (overlay wins on conflicts), and
+// remaining scalar/slice fields from overlay take precedence when set.
+func MergeVSCodeSettings(base, overlay VSCodeSettings) VSCodeSettings {
+	merged := base
+	merged.CMakeConfigureSettings = mergeConfigureValueMaps(base.CMakeConfigureSettings, overlay.CMakeConfigureSettings)
+	merged.CMakeEnvironment = mergeStringMaps(base.CMakeEnvironment, overlay.CMakeEnvironment)
+	if len(overlay.CMakeConfigureArguments) > 0 {
+		merged.CMakeConfigureArguments = overlay.CMakeConfigureArguments
+	}
+	if overlay.CMakeGenerator != "" {
+		merged.CMakeGenerator = overlay.CMakeGenerator
+	}
+	if overlay.CMakeBuildDirectory != "" {
+		merged.CMakeBuildDirectory = overlay.CMakeBuildDirectory
+	}
+	if len(overlay.CMakeBuildArgs) > 0 {
+		merged.CMakeBuildArgs = overlay.CMakeBuildArgs
+	}
+	if len(overlay.CMakeTestArgs) > 0 {
+		merged.CMakeTestArgs = overlay.CMakeTestArgs
+	}
+	if overlay.CMakeInstallPrefix != "" {
+		merged.CMakeInstallPrefix = overlay.CMakeInstallPrefix
+	}
+	return merged
+}
+
+// LoadSettings resolves the effective VSCodeSettings from .vscode/settings.json
+// and/or CMakePresets.json according to source, which must be one of
+// "vscode", "presets", or "merged". ctx controls "${...}" variable expansion
+// of the .vscode/settings.json contents (see ReadVSCodeSettings), and
+// validateMode gates schema validation of the .vscode/settings.json contents
+// (see ValidateSettings). Presets are not schema-validated.
+func LoadSettings(vscodeFile, presetsFile, presetName, source string, ctx ExpandContext, validateMode string) (VSCodeSettings, error) {
+	switch source {
+	case "vscode":
+		return ReadVSCodeSettings(vscodeFile, ctx, validateMode)
+	case "presets":
+		if presetName == "" {
+			return VSCodeSettings{}, fmt.Errorf("VCC_SOURCE=presets requires VCC_PRESET to select a configure preset")
+		}
+		return ReadCMakePresetSettings(presetsFile, presetName)
+	case "merged":
+		vscodeSettings, err := ReadVSCodeSettings(vscodeFile, ctx, validateMode)
+		if err != nil {
+			return VSCodeSettings{}, err
+		}
+		if presetName == "" {
+			return vscodeSettings, nil
+		}
+		presetSettings, err := ReadCMakePresetSettings(presetsFile, presetName)
+		if err != nil {
+			return VSCodeSettings{}, err
+		}
+		return MergeVSCodeSettings(presetSettings, vscodeSettings), nil
+	default:
+		return VSCodeSettings{}, fmt.Errorf("unknown VCC_SOURCE %q: must be one of vscode|presets|merged", source)
+	}
+}
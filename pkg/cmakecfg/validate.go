@@ -0,0 +1,131 @@
+// Copyright 2022 Markus Holmström (MawKKe) markus@mawkke.fi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmakecfg
+
+// ---- Settings schema validation ----------------------------------------
+//
+// settings.json is hand-edited, so it's easy to get a value's shape wrong
+// (e.g. a number where cmake.generator expects a string). ValidateSettings
+// checks the raw (post-jsonc.ToJSON) bytes against an embedded JSON Schema
+// covering the subset of keys vcc understands, and reports exactly which
+// key failed via its JSON pointer. It does not attempt to validate
+// cmake-tools-kits.json or cmake-variants.json.
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+//go:embed schema/settings.schema.json
+var settingsSchemaJSON []byte
+
+var settingsSchema = mustCompileSettingsSchema()
+
+func mustCompileSettingsSchema() *jsonschema.Schema {
+	const resourceName = "settings.schema.json"
+	compiler := jsonschema.NewCompiler()
+	if err := compiler.AddResource(resourceName, bytes.NewReader(settingsSchemaJSON)); err != nil {
+		panic(fmt.Sprintf("cmakecfg: embedded settings schema is invalid: %v", err))
+	}
+	schema, err := compiler.Compile(resourceName)
+	if err != nil {
+		panic(fmt.Sprintf("cmakecfg: embedded settings schema is invalid: %v", err))
+	}
+	return schema
+}
+
+// ValidationIssue is a single schema violation, identified by the JSON
+// pointer of the offending value.
+type ValidationIssue struct {
+	Pointer string
+	Message string
+}
+
+// ValidationError is returned when settings.json fails schema validation
+// under VCC_VALIDATE=strict.
+type ValidationError struct {
+	Issues []ValidationIssue
+}
+
+func (e *ValidationError) Error() string {
+	parts := make([]string, len(e.Issues))
+	for i, issue := range e.Issues {
+		parts[i] = fmt.Sprintf("%s: %s", issue.Pointer, issue.Message)
+	}
+	return "settings.json failed schema validation:\n" + strings.Join(parts, "\n")
+}
+
+// flattenValidationError walks a jsonschema.ValidationError tree (which
+// nests a cause per failed subschema) into a flat list of leaf issues.
+func flattenValidationError(err *jsonschema.ValidationError) []ValidationIssue {
+	if len(err.Causes) == 0 {
+		return []ValidationIssue{{
+			Pointer: err.InstanceLocation,
+			Message: err.Message,
+		}}
+	}
+	var issues []ValidationIssue
+	for _, cause := range err.Causes {
+		issues = append(issues, flattenValidationError(cause)...)
+	}
+	return issues
+}
+
+// ValidateSettings checks rawJSON (the jsonc-stripped settings.json
+// contents) against the embedded schema, according to mode:
+//
+//   - "off" (default): validation is skipped entirely.
+//   - "warn": violations are printed to stderr but do not fail parsing.
+//   - "strict": violations are returned as a *ValidationError.
+//
+// An unknown mode is itself an error.
+func ValidateSettings(rawJSON []byte, mode string) error {
+	switch mode {
+	case "", "off":
+		return nil
+	case "warn", "strict":
+		// handled below
+	default:
+		return fmt.Errorf("unknown VCC_VALIDATE %q: must be one of strict|warn|off", mode)
+	}
+
+	var instance interface{}
+	if err := json.Unmarshal(rawJSON, &instance); err != nil {
+		return err
+	}
+
+	err := settingsSchema.Validate(instance)
+	if err == nil {
+		return nil
+	}
+
+	validationErr, ok := err.(*jsonschema.ValidationError)
+	if !ok {
+		return err
+	}
+
+	schemaErr := &ValidationError{Issues: flattenValidationError(validationErr)}
+	if mode == "warn" {
+		fmt.Fprintln(os.Stderr, schemaErr)
+		return nil
+	}
+	return schemaErr
+}
@@ -0,0 +1,141 @@
+// Copyright 2022 Markus Holmström (MawKKe) markus@mawkke.fi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmakecfg
+
+// ---- cmake-variants.json support ---------------------------------------
+//
+// VSCode's CMake Tools extension groups build variants into named "axes"
+// (buildType, linkage, ...), each offering a handful of named choices. A
+// variant selection picks (at most) one choice per axis, joined with "+",
+// e.g. "debug+static". We only support the JSON form of cmake-variants; the
+// equivalent YAML file is not parsed.
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/tidwall/jsonc"
+)
+
+// VariantChoice is one choice of a cmake-variants.json axis.
+type VariantChoice struct {
+	Short     string                    `json:"short"`
+	Long      string                    `json:"long"`
+	BuildType string                    `json:"buildType"`
+	Settings  map[string]ConfigureValue `json:"settings"`
+	Env       map[string]string         `json:"env"`
+}
+
+// VariantAxis is one top-level entry of a cmake-variants.json file.
+type VariantAxis struct {
+	Default string                   `json:"default"`
+	Choices map[string]VariantChoice `json:"choices"`
+}
+
+// VariantFile is the root object of a cmake-variants.json file: a map of
+// axis name to its definition.
+type VariantFile map[string]VariantAxis
+
+// ReadVariants reads and parses a "cmake-variants.json"-style file.
+func ReadVariants(inputFile string) (VariantFile, error) {
+	contents, err := os.ReadFile(inputFile)
+	if err != nil {
+		return nil, err
+	}
+	return ParseVariants(contents)
+}
+
+// ParseVariants parses cmake-variants.json contents. Like VSCode's
+// settings.json, these files may contain comments.
+func ParseVariants(inputString []byte) (VariantFile, error) {
+	var variants VariantFile
+	if err := json.Unmarshal(jsonc.ToJSON(inputString), &variants); err != nil {
+		return nil, err
+	}
+	return variants, nil
+}
+
+// findChoice looks up choiceName across every axis, since a variant
+// selection names choices directly without saying which axis they belong to.
+func (variants VariantFile) findChoice(choiceName string) (VariantChoice, error) {
+	for _, axis := range variants {
+		if choice, ok := axis.Choices[choiceName]; ok {
+			return choice, nil
+		}
+	}
+	return VariantChoice{}, fmt.Errorf("no variant choice named %q", choiceName)
+}
+
+// SelectVariant resolves a "+"-separated variant selection (e.g.
+// "debug+static") into a VSCodeSettings value: each choice's buildType (if
+// any) becomes CMAKE_BUILD_TYPE, and its settings/env are merged in, with
+// later choices in the selection winning on conflicts.
+func (variants VariantFile) SelectVariant(selection string) (VSCodeSettings, error) {
+	var settings VSCodeSettings
+	for _, choiceName := range strings.Split(selection, "+") {
+		choice, err := variants.findChoice(choiceName)
+		if err != nil {
+			return VSCodeSettings{}, err
+		}
+		overlay := VSCodeSettings{
+			CMakeConfigureSettings: choice.Settings,
+			CMakeEnvironment:       choice.Env,
+		}
+		if choice.BuildType != "" {
+			overlay.CMakeConfigureSettings = mergeConfigureValueMaps(overlay.CMakeConfigureSettings,
+				map[string]ConfigureValue{"CMAKE_BUILD_TYPE": {Value: choice.BuildType}})
+		}
+		settings = MergeVSCodeSettings(settings, overlay)
+	}
+	return settings, nil
+}
+
+// LoadKitAndVariantSettings resolves the VSCodeSettings contributed by an
+// optional selected kit and/or variant. Both kitName and variantSelection
+// may be left empty, in which case the corresponding file is not even read.
+// When both are set, the variant's settings take precedence over the kit's
+// on conflicts (e.g. a variant's CMAKE_BUILD_TYPE overriding one set by the
+// kit's cmakeSettings).
+func LoadKitAndVariantSettings(kitsFile, kitName, variantsFile, variantSelection string) (VSCodeSettings, error) {
+	var settings VSCodeSettings
+
+	if kitName != "" {
+		kits, err := ReadKits(kitsFile)
+		if err != nil {
+			return VSCodeSettings{}, err
+		}
+		kit, err := FindKit(kits, kitName)
+		if err != nil {
+			return VSCodeSettings{}, err
+		}
+		settings = MergeVSCodeSettings(settings, kit.ToVSCodeSettings())
+	}
+
+	if variantSelection != "" {
+		variants, err := ReadVariants(variantsFile)
+		if err != nil {
+			return VSCodeSettings{}, err
+		}
+		variantSettings, err := variants.SelectVariant(variantSelection)
+		if err != nil {
+			return VSCodeSettings{}, err
+		}
+		settings = MergeVSCodeSettings(settings, variantSettings)
+	}
+
+	return settings, nil
+}